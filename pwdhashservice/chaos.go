@@ -0,0 +1,156 @@
+package pwdhashservice
+
+import (
+    "encoding/json"
+    "fmt"
+    "math/rand"
+    "net/http"
+    "sync"
+    "time"
+)
+
+//Parameters controlling chaos mode, the deterministic injection of faults into request handling.
+type ChaosConfig struct {
+    Enabled            bool          `json:"enabled"`
+    FailureRate        float64       `json:"failureRate"`
+    DropRate           float64       `json:"dropRate"`
+    ExtraLatencyMean   time.Duration `json:"extraLatencyMean"`
+    ExtraLatencyStddev time.Duration `json:"extraLatencyStddev"`
+    Seed               *int64        `json:"seed,omitempty"`
+}
+
+//Holds the current chaos configuration and the RNG used to evaluate it.
+type chaosController struct {
+    config ChaosConfig
+    rng *rand.Rand
+    mutex sync.Mutex
+}
+
+//Builds a chaos controller with the given configuration.
+func newChaosController(cfg ChaosConfig) *chaosController {
+    cc := &chaosController{}
+    cc.setConfig(cfg)
+    return cc
+}
+
+//Replaces the chaos configuration, reseeding the RNG so a given seed reproduces the same sequence of decisions.
+func (cc *chaosController) setConfig(cfg ChaosConfig) {
+    cc.mutex.Lock()
+    defer cc.mutex.Unlock()
+    seed := time.Now().UnixNano()
+    if cfg.Seed != nil {
+        seed = *cfg.Seed
+    }
+    cc.config = cfg
+    cc.rng = rand.New(rand.NewSource(seed))
+}
+
+//Returns the current chaos configuration.
+func (cc *chaosController) getConfig() ChaosConfig {
+    cc.mutex.Lock()
+    defer cc.mutex.Unlock()
+    return cc.config
+}
+
+//Decides whether a request should be failed outright, per the configured failure rate.
+func (cc *chaosController) shouldFail() bool {
+    cc.mutex.Lock()
+    defer cc.mutex.Unlock()
+    return cc.config.Enabled && cc.rng.Float64() < cc.config.FailureRate
+}
+
+//Decides whether a request's connection should be dropped mid-response, per the configured drop rate.
+func (cc *chaosController) shouldDrop() bool {
+    cc.mutex.Lock()
+    defer cc.mutex.Unlock()
+    return cc.config.Enabled && cc.rng.Float64() < cc.config.DropRate
+}
+
+//Picks the HTTP status code for an injected failure.
+func (cc *chaosController) failureStatusCode() int {
+    cc.mutex.Lock()
+    defer cc.mutex.Unlock()
+    if cc.rng.Float64() < 0.5 {
+        return http.StatusInternalServerError
+    }
+    return http.StatusServiceUnavailable
+}
+
+//Samples additional latency from the configured normal distribution, floored at zero.
+func (cc *chaosController) extraLatency() time.Duration {
+    cc.mutex.Lock()
+    defer cc.mutex.Unlock()
+    if !cc.config.Enabled || (cc.config.ExtraLatencyMean == 0 && cc.config.ExtraLatencyStddev == 0) {
+        return 0
+    }
+    sample := cc.rng.NormFloat64()*float64(cc.config.ExtraLatencyStddev) + float64(cc.config.ExtraLatencyMean)
+    if sample < 0 {
+        return 0
+    }
+    return time.Duration(sample)
+}
+
+//Injects a chaos failure response if configured to do so, returning true if the request was handled.
+func (phs *PwdHashServer) injectChaosFailure(responseWriter http.ResponseWriter) bool {
+    if !phs.chaos.shouldFail() {
+        return false
+    }
+    http.Error(responseWriter, "Injected chaos failure.", phs.chaos.failureStatusCode())
+    return true
+}
+
+//Injects a dropped connection if configured to do so, returning true if the request was handled.
+func (phs *PwdHashServer) injectChaosDrop(responseWriter http.ResponseWriter) bool {
+    if !phs.chaos.shouldDrop() {
+        return false
+    }
+    hijacker, ok := responseWriter.(http.Hijacker)
+    if !ok {
+        http.Error(responseWriter, "Injected chaos failure.", http.StatusServiceUnavailable)
+        return true
+    }
+    conn, _, err := hijacker.Hijack()
+    if err != nil {
+        http.Error(responseWriter, "Injected chaos failure.", http.StatusServiceUnavailable)
+        return true
+    }
+    conn.Close()
+    return true
+}
+
+//Builds and returns the chaos admin handler, which reads or replaces the chaos configuration.
+func (phs *PwdHashServer) getChaosAdminHandler() http.HandlerFunc {
+    return http.HandlerFunc(func (responseWriter http.ResponseWriter, request *http.Request) {
+        switch request.Method {
+        case http.MethodGet:
+            phs.writeChaosConfig(responseWriter)
+        case http.MethodPost:
+            phs.updateChaosConfig(responseWriter, request)
+        default:
+            http.Error(responseWriter, "Method not allowed.", http.StatusMethodNotAllowed)
+        }
+    })
+}
+
+//Writes the current chaos configuration to the response as JSON.
+func (phs *PwdHashServer) writeChaosConfig(responseWriter http.ResponseWriter) {
+    jsonConfig, err := json.Marshal(phs.chaos.getConfig())
+    if err != nil {
+        phs.logger.Printf("ERROR: Unable to marshal chaos configuration to JSON.")
+        http.Error(responseWriter, "Unable to marshal chaos configuration.", http.StatusInternalServerError)
+        return
+    }
+    responseWriter.Header().Set("Content-Type", "application/json")
+    fmt.Fprint(responseWriter, string(jsonConfig))
+}
+
+//Decodes a chaos configuration from the request body and applies it before echoing it back.
+func (phs *PwdHashServer) updateChaosConfig(responseWriter http.ResponseWriter, request *http.Request) {
+    var config ChaosConfig
+    if err := json.NewDecoder(request.Body).Decode(&config); err != nil {
+        http.Error(responseWriter, "Unable to parse chaos configuration.", http.StatusBadRequest)
+        return
+    }
+    phs.chaos.setConfig(config)
+    phs.writeChaosConfig(responseWriter)
+}
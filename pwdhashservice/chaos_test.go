@@ -0,0 +1,130 @@
+package pwdhashservice
+
+import (
+    "bytes"
+    "encoding/json"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+    "time"
+)
+
+//Returns a fixed RNG seed so chaos decisions are reproducible across test runs.
+func fixedChaosSeed() *int64 {
+    seed := int64(42)
+    return &seed
+}
+
+//Tests pwdhashservice.chaosController.shouldFail() bool
+func TestChaosControllerShouldFail(t *testing.T) {
+    t.Run("TestAlwaysFails", func(t *testing.T) {
+        cc := newChaosController(ChaosConfig{Enabled: true, FailureRate: 1, Seed: fixedChaosSeed()})
+        if !cc.shouldFail() {
+            t.Fail()
+        }
+    })
+    t.Run("TestNeverFails", func(t *testing.T) {
+        cc := newChaosController(ChaosConfig{Enabled: true, FailureRate: 0, Seed: fixedChaosSeed()})
+        if cc.shouldFail() {
+            t.Fail()
+        }
+    })
+    t.Run("TestDisabled", func(t *testing.T) {
+        cc := newChaosController(ChaosConfig{Enabled: false, FailureRate: 1, Seed: fixedChaosSeed()})
+        if cc.shouldFail() {
+            t.Fail()
+        }
+    })
+}
+
+//Tests pwdhashservice.chaosController.shouldDrop() bool
+func TestChaosControllerShouldDrop(t *testing.T) {
+    t.Run("TestAlwaysDrops", func(t *testing.T) {
+        cc := newChaosController(ChaosConfig{Enabled: true, DropRate: 1, Seed: fixedChaosSeed()})
+        if !cc.shouldDrop() {
+            t.Fail()
+        }
+    })
+    t.Run("TestNeverDrops", func(t *testing.T) {
+        cc := newChaosController(ChaosConfig{Enabled: true, DropRate: 0, Seed: fixedChaosSeed()})
+        if cc.shouldDrop() {
+            t.Fail()
+        }
+    })
+}
+
+//Tests pwdhashservice.chaosController.extraLatency() time.Duration
+func TestChaosControllerExtraLatency(t *testing.T) {
+    t.Run("TestZeroWhenUnconfigured", func(t *testing.T) {
+        cc := newChaosController(ChaosConfig{Enabled: true, Seed: fixedChaosSeed()})
+        if cc.extraLatency() != 0 {
+            t.Fail()
+        }
+    })
+    t.Run("TestZeroWhenDisabled", func(t *testing.T) {
+        cc := newChaosController(ChaosConfig{Enabled: false, ExtraLatencyMean: 100 * time.Millisecond, Seed: fixedChaosSeed()})
+        if cc.extraLatency() != 0 {
+            t.Fail()
+        }
+    })
+    t.Run("TestNeverNegative", func(t *testing.T) {
+        cc := newChaosController(ChaosConfig{Enabled: true, ExtraLatencyStddev: 100 * time.Millisecond, Seed: fixedChaosSeed()})
+        for i := 0; i < 100; i++ {
+            if cc.extraLatency() < 0 {
+                t.Fail()
+            }
+        }
+    })
+}
+
+//Tests handler returned by pwdhashservice.getChaosAdminHandler() http.HandlerFunc
+func TestChaosAdminHandler(t *testing.T) {
+    phs := NewPasswordHashingServer(":8080")
+    chaosHandler := phs.getChaosAdminHandler()
+
+    t.Run("TestGetDefaultConfig", func(t *testing.T) {
+        request, _ := http.NewRequest("GET", "/admin/chaos", nil)
+        response := httptest.NewRecorder()
+        chaosHandler(response, request)
+        var config ChaosConfig
+        if err := json.Unmarshal(response.Body.Bytes(), &config); err != nil {
+            t.Fatalf("Unable to unmarshal chaos configuration: %v", err)
+        }
+        if config.Enabled {
+            t.Fail()
+        }
+    })
+
+    t.Run("TestPostUpdatesConfig", func(t *testing.T) {
+        requestBody, _ := json.Marshal(ChaosConfig{Enabled: true, FailureRate: 0.5})
+        request, _ := http.NewRequest("POST", "/admin/chaos", bytes.NewReader(requestBody))
+        response := httptest.NewRecorder()
+        chaosHandler(response, request)
+        var config ChaosConfig
+        if err := json.Unmarshal(response.Body.Bytes(), &config); err != nil {
+            t.Fatalf("Unable to unmarshal chaos configuration: %v", err)
+        }
+        if !config.Enabled || config.FailureRate != 0.5 {
+            t.Fail()
+        }
+    })
+}
+
+//Tests that an injected chaos failure on pwdhashservice.getStatsHandler() leaves hashing statistics consistent.
+func TestStatsHandlerWithInjectedFailure(t *testing.T) {
+    phs := NewPasswordHashingServer(":8080", WithChaos(ChaosConfig{Enabled: true, FailureRate: 1, Seed: fixedChaosSeed()}))
+    statsHandler := phs.getStatsHandler()
+    request, _ := http.NewRequest("GET", "/stats", nil)
+    response := httptest.NewRecorder()
+    statsHandler(response, request)
+    if response.Code != http.StatusInternalServerError && response.Code != http.StatusServiceUnavailable {
+        t.Logf("Response code: %d", response.Code)
+        t.Fail()
+    }
+    if phs.hashStats.totalHashed != 0 {
+        t.Fail()
+    }
+    if phs.threadInfo.numWorkingThreads != 0 {
+        t.Fail()
+    }
+}
@@ -0,0 +1,68 @@
+package pwdhashservice
+
+import (
+    "fmt"
+    "log"
+    "net"
+)
+
+//StatsD bucket names used when reporting server metrics.
+const (
+    hashCountBucket           = "passwordhashingservice.hash.count"
+    hashDurationBucket        = "passwordhashingservice.hash.duration_ms"
+    statsHitsBucket           = "passwordhashingservice.stats.count"
+    shutdownHitsBucket        = "passwordhashingservice.shutdown.count"
+    restartHitsBucket         = "passwordhashingservice.restart.count"
+    workingThreadsGaugeBucket = "passwordhashingservice.threads.working"
+)
+
+//Reports hashing metrics to an external collector.
+type MetricsSink interface {
+    Counter(bucket string, value int64)
+    Timing(bucket string, durationMs int64)
+    Gauge(bucket string, value int64)
+}
+
+//A MetricsSink that discards every metric it is given.
+type noopMetricsSink struct{}
+
+func (noopMetricsSink) Counter(bucket string, value int64)     {}
+func (noopMetricsSink) Timing(bucket string, durationMs int64) {}
+func (noopMetricsSink) Gauge(bucket string, value int64)       {}
+
+//Reports metrics to a StatsD daemon over UDP using the standard wire format.
+type statsdSink struct {
+    conn   net.Conn
+    logger *log.Logger
+}
+
+//Opens a UDP connection to a StatsD daemon at the given address.
+func newStatsdSink(addr string, logger *log.Logger) (*statsdSink, error) {
+    conn, err := net.Dial("udp", addr)
+    if err != nil {
+        return nil, err
+    }
+    return &statsdSink{conn: conn, logger: logger}, nil
+}
+
+//Reports a counter metric, incrementing the named bucket by value.
+func (s *statsdSink) Counter(bucket string, value int64) {
+    s.send(fmt.Sprintf("%s:%d|c", bucket, value))
+}
+
+//Reports a timer metric, recording durationMs against the named bucket.
+func (s *statsdSink) Timing(bucket string, durationMs int64) {
+    s.send(fmt.Sprintf("%s:%d|ms", bucket, durationMs))
+}
+
+//Reports a gauge metric, setting the named bucket to value.
+func (s *statsdSink) Gauge(bucket string, value int64) {
+    s.send(fmt.Sprintf("%s:%d|g", bucket, value))
+}
+
+//Writes a single metric as one UDP datagram, logging (but not failing) on error.
+func (s *statsdSink) send(datagram string) {
+    if _, err := s.conn.Write([]byte(datagram)); err != nil {
+        s.logger.Printf("ERROR: Unable to write metric to StatsD sink: %v", err)
+    }
+}
@@ -0,0 +1,102 @@
+package pwdhashservice
+
+import (
+    "log"
+    "net"
+    "os"
+    "testing"
+)
+
+//Starts a local UDP listener and returns it along with its address.
+func newUdpListener(t *testing.T) (*net.UDPConn, string) {
+    conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 0})
+    if err != nil {
+        t.Fatalf("Unable to start UDP listener: %v", err)
+    }
+    return conn, conn.LocalAddr().String()
+}
+
+//Reads a single datagram from the listener.
+func readDatagram(t *testing.T, conn *net.UDPConn) string {
+    buffer := make([]byte, 512)
+    n, _, err := conn.ReadFromUDP(buffer)
+    if err != nil {
+        t.Fatalf("Unable to read datagram: %v", err)
+    }
+    return string(buffer[:n])
+}
+
+//Tests pwdhashservice.newStatsdSink(addr string, logger *log.Logger) (*statsdSink, error)
+func TestStatsdSink(t *testing.T) {
+    logger := log.New(os.Stdout, "passwordhashingservice: ", log.LstdFlags)
+
+    t.Run("TestCounter", func(t *testing.T) {
+        listener, addr := newUdpListener(t)
+        defer listener.Close()
+        sink, err := newStatsdSink(addr, logger)
+        if err != nil {
+            t.Fatalf("Unable to create StatsD sink: %v", err)
+        }
+        sink.Counter("passwordhashingservice.hash.count", 1)
+        datagram := readDatagram(t, listener)
+        expected := "passwordhashingservice.hash.count:1|c"
+        if datagram != expected {
+            t.Logf("Datagram: %s", datagram)
+            t.Fail()
+        }
+    })
+
+    t.Run("TestTiming", func(t *testing.T) {
+        listener, addr := newUdpListener(t)
+        defer listener.Close()
+        sink, err := newStatsdSink(addr, logger)
+        if err != nil {
+            t.Fatalf("Unable to create StatsD sink: %v", err)
+        }
+        sink.Timing("passwordhashingservice.hash.duration_ms", 5000)
+        datagram := readDatagram(t, listener)
+        expected := "passwordhashingservice.hash.duration_ms:5000|ms"
+        if datagram != expected {
+            t.Logf("Datagram: %s", datagram)
+            t.Fail()
+        }
+    })
+
+    t.Run("TestGauge", func(t *testing.T) {
+        listener, addr := newUdpListener(t)
+        defer listener.Close()
+        sink, err := newStatsdSink(addr, logger)
+        if err != nil {
+            t.Fatalf("Unable to create StatsD sink: %v", err)
+        }
+        sink.Gauge("passwordhashingservice.threads.working", 3)
+        datagram := readDatagram(t, listener)
+        expected := "passwordhashingservice.threads.working:3|g"
+        if datagram != expected {
+            t.Logf("Datagram: %s", datagram)
+            t.Fail()
+        }
+    })
+}
+
+//Tests that pwdhashservice.WithStatsdSink(addr string) Option wires a working sink into the server.
+func TestWithStatsdSink(t *testing.T) {
+    listener, addr := newUdpListener(t)
+    defer listener.Close()
+    phs := NewPasswordHashingServer(":8080", WithStatsdSink(addr))
+    phs.metrics.Counter("passwordhashingservice.hash.count", 1)
+    datagram := readDatagram(t, listener)
+    expected := "passwordhashingservice.hash.count:1|c"
+    if datagram != expected {
+        t.Logf("Datagram: %s", datagram)
+        t.Fail()
+    }
+}
+
+//Tests that pwdhashservice.noopMetricsSink discards metrics without error.
+func TestNoopMetricsSink(t *testing.T) {
+    sink := noopMetricsSink{}
+    sink.Counter("bucket", 1)
+    sink.Timing("bucket", 1)
+    sink.Gauge("bucket", 1)
+}
@@ -5,18 +5,27 @@ import (
     "crypto/sha512"
     "encoding/base64"
     "encoding/json"
+    "errors"
     "fmt"
     "log"
     "net/http"
     "os"
     "sync"
+    "sync/atomic"
     "time"
 )
 
+//The maximum amount of time to wait for the HTTP server to shut down during a restart.
+const restartShutdownTimeout = 10 * time.Second
+
+//Returned when a restart is requested while a shutdown or restart is already in progress.
+var errRestartInProgress = errors.New("a server shutdown or restart is already in progress")
+
 //Data pertaining to server threading information.
 type threadingInfo struct {
     numWorkingThreads int
     numWorkingThreadsMutex sync.Mutex
+    workingThreadsWaitGroup sync.WaitGroup
 }
 
 //Data pertaining to server hashing statistics.
@@ -30,20 +39,51 @@ type hashingStats struct {
 //Defines a password hashing server.
 type PwdHashServer struct {
     httpServer *http.Server
+    httpServerMutex sync.Mutex
     logger *log.Logger
     hashStats *hashingStats
     threadInfo *threadingInfo
-    shutdownInProgress bool
+    shutdownInProgress atomic.Bool
+    restartRequested atomic.Bool
+    metrics MetricsSink
+    chaos *chaosController
+}
+
+//An option that customizes a PwdHashServer at construction time.
+type Option func(*PwdHashServer)
+
+//Configures the server to report metrics to a StatsD daemon over UDP at the given address.
+func WithStatsdSink(addr string) Option {
+    return func(phs *PwdHashServer) {
+        sink, err := newStatsdSink(addr, phs.logger)
+        if err != nil {
+            phs.logger.Printf("ERROR: Unable to open StatsD sink at %s: %v", addr, err)
+            return
+        }
+        phs.metrics = sink
+    }
+}
+
+//Configures the server's chaos mode using the given initial configuration. Chaos mode can also be
+//reconfigured at runtime through the /admin/chaos endpoint.
+func WithChaos(cfg ChaosConfig) Option {
+    return func(phs *PwdHashServer) {
+        phs.chaos = newChaosController(cfg)
+    }
 }
 
 //Builds and returns a server instance.
-func NewPasswordHashingServer(addr string) *PwdHashServer {
+func NewPasswordHashingServer(addr string, opts ...Option) *PwdHashServer {
     pwdHashingServer := &PwdHashServer{}
     pwdHashingServer.logger = log.New(os.Stdout, "passwordhashingservice: ", log.LstdFlags)
     pwdHashingServer.httpServer = pwdHashingServer.getHttpServer(addr)
     pwdHashingServer.hashStats = &hashingStats{totalHashed : 0, totalHashingTime : 0, totalHashedMutex : sync.Mutex{}, totalHashingTimeMutex : sync.Mutex{}}
     pwdHashingServer.threadInfo = &threadingInfo{numWorkingThreads : 0, numWorkingThreadsMutex : sync.Mutex{}}
-    pwdHashingServer.shutdownInProgress = false
+    pwdHashingServer.metrics = noopMetricsSink{}
+    pwdHashingServer.chaos = newChaosController(ChaosConfig{})
+    for _, opt := range opts {
+        opt(pwdHashingServer)
+    }
     return pwdHashingServer
 }
 
@@ -63,34 +103,71 @@ func (phs *PwdHashServer) getServerMux() *http.ServeMux {
     serveMux.Handle("/hash", phs.getHashingHandler())
     serveMux.Handle("/shutdown", phs.getShutdownHandler())
     serveMux.Handle("/stats", phs.getStatsHandler())
+    serveMux.Handle("/admin/chaos", phs.getChaosAdminHandler())
+    serveMux.Handle("/restart", phs.getRestartHandler())
     return serveMux
 }
 
 //Builds and returns the hashing handler.
 func (phs *PwdHashServer) getHashingHandler() http.HandlerFunc {
     return http.HandlerFunc(func (responseWriter http.ResponseWriter, request *http.Request) {
-        if phs.shutdownInProgress {
+        if !phs.admitRequest() {
+            return
+        }
+        if phs.injectChaosFailure(responseWriter) {
+            phs.decrementWorkingThreads()
+            return
+        }
+        if phs.injectChaosDrop(responseWriter) {
+            phs.decrementWorkingThreads()
             return
         }
-        phs.incrementWorkingThreads()
         startTime := time.Now()
-        time.Sleep(5 * time.Second)
+        time.Sleep(5*time.Second + phs.chaos.extraLatency())
         password := request.URL.Query().Get("password")
         hash := getSha512HashString([]byte(password))
         fmt.Fprintf(responseWriter, "%s", hash)
-        phs.incrementTotalHashed()
         elapsedTime := time.Since(startTime)
-        phs.increaseTotalHashingTime(elapsedTime)
+        phs.updateHashingStats(1, elapsedTime)
+        phs.metrics.Counter(hashCountBucket, 1)
+        phs.metrics.Timing(hashDurationBucket, int64(elapsedTime/time.Millisecond))
         phs.logger.Printf("Hashed password \"%s\" into \"%s\".", password, hash)
         phs.decrementWorkingThreads()
     })
 }
 
+//Admits a new request unless a shutdown or restart is already underway, incrementing the working
+//thread count atomically with that check so that no request can be admitted after
+//waitForWorkingThreads has begun draining.
+func (phs *PwdHashServer) admitRequest() bool {
+    phs.threadInfo.numWorkingThreadsMutex.Lock()
+    defer phs.threadInfo.numWorkingThreadsMutex.Unlock()
+    if phs.shutdownInProgress.Load() {
+        return false
+    }
+    phs.threadInfo.numWorkingThreads += 1
+    numWorkingThreads := phs.threadInfo.numWorkingThreads
+    phs.threadInfo.workingThreadsWaitGroup.Add(1)
+    phs.metrics.Gauge(workingThreadsGaugeBucket, int64(numWorkingThreads))
+    return true
+}
+
+//Begins a shutdown or restart, serialized against admitRequest so that no new request is admitted
+//once this succeeds. Returns false if a shutdown or restart is already in progress.
+func (phs *PwdHashServer) beginShutdown() bool {
+    phs.threadInfo.numWorkingThreadsMutex.Lock()
+    defer phs.threadInfo.numWorkingThreadsMutex.Unlock()
+    return phs.shutdownInProgress.CompareAndSwap(false, true)
+}
+
 //Increments the number of working threads.
 func (phs *PwdHashServer) incrementWorkingThreads() {
     phs.threadInfo.numWorkingThreadsMutex.Lock()
     phs.threadInfo.numWorkingThreads += 1
+    numWorkingThreads := phs.threadInfo.numWorkingThreads
     phs.threadInfo.numWorkingThreadsMutex.Unlock()
+    phs.threadInfo.workingThreadsWaitGroup.Add(1)
+    phs.metrics.Gauge(workingThreadsGaugeBucket, int64(numWorkingThreads))
 }
 
 //Computes a hash from an array of bytes using SHA-512.
@@ -101,15 +178,12 @@ func getSha512HashString(bytes []byte) string {
     return sha512Hash
 }
 
-//Increments the count of total hashings.
-func (phs *PwdHashServer) incrementTotalHashed() {
+//Updates hashing statistics with the results of a completed hash operation.
+func (phs *PwdHashServer) updateHashingStats(numHashed int64, additionalTime time.Duration) {
     phs.hashStats.totalHashedMutex.Lock()
-    phs.hashStats.totalHashed += 1
+    phs.hashStats.totalHashed += numHashed
     phs.hashStats.totalHashedMutex.Unlock()
-}
 
-//Increases the total time spent hashing by the provided duration.
-func (phs *PwdHashServer) increaseTotalHashingTime(additionalTime time.Duration) {
     phs.hashStats.totalHashingTimeMutex.Lock()
     phs.hashStats.totalHashingTime += int64(additionalTime/time.Millisecond)
     phs.hashStats.totalHashingTimeMutex.Unlock()
@@ -119,39 +193,123 @@ func (phs *PwdHashServer) increaseTotalHashingTime(additionalTime time.Duration)
 func (phs *PwdHashServer) decrementWorkingThreads() {
     phs.threadInfo.numWorkingThreadsMutex.Lock()
     phs.threadInfo.numWorkingThreads -= 1
+    numWorkingThreads := phs.threadInfo.numWorkingThreads
     phs.threadInfo.numWorkingThreadsMutex.Unlock()
+    phs.threadInfo.workingThreadsWaitGroup.Done()
+    phs.metrics.Gauge(workingThreadsGaugeBucket, int64(numWorkingThreads))
 }
 
 //Builds and returns the shutdown handler.
 func (phs *PwdHashServer) getShutdownHandler() http.HandlerFunc {
     return http.HandlerFunc(func (responseWriter http.ResponseWriter, request *http.Request) {
-        if !phs.shutdownInProgress {
-            phs.shutdownInProgress = true
-            phs.logger.Print("Shutting down server...")
-            phs.waitForWorkingThreads()
-            if err := phs.httpServer.Shutdown(context.Background()); err != nil {
-                fmt.Fprint(responseWriter, "Unable to shutdown server.")
-                phs.logger.Fatalf("ERROR: Unable to gracefully shutdown server:\n %v\n", err)
-            }
-        } else {
+        phs.metrics.Counter(shutdownHitsBucket, 1)
+        if phs.injectChaosFailure(responseWriter) {
+            return
+        }
+        if phs.injectChaosDrop(responseWriter) {
+            return
+        }
+        if !phs.beginShutdown() {
             fmt.Fprintf(responseWriter, "A server shutdown is already in progress.")
+            return
+        }
+        phs.logger.Print("Shutting down server...")
+        phs.waitForWorkingThreads()
+        if err := phs.httpServer.Shutdown(context.Background()); err != nil {
+            fmt.Fprint(responseWriter, "Unable to shutdown server.")
+            phs.logger.Fatalf("ERROR: Unable to gracefully shutdown server:\n %v\n", err)
         }
     })
 }
 
 //Waits for threads corresponding to in progress connections to complete.
 func (phs *PwdHashServer) waitForWorkingThreads() {
-    for phs.threadInfo.numWorkingThreads > 0 {
+    phs.threadInfo.workingThreadsWaitGroup.Wait()
+}
+
+//Builds and returns the restart handler.
+func (phs *PwdHashServer) getRestartHandler() http.HandlerFunc {
+    return http.HandlerFunc(func (responseWriter http.ResponseWriter, request *http.Request) {
+        phs.metrics.Counter(restartHitsBucket, 1)
+        if phs.injectChaosFailure(responseWriter) {
+            return
+        }
+        if phs.injectChaosDrop(responseWriter) {
+            return
+        }
+        if !phs.beginShutdown() {
+            fmt.Fprint(responseWriter, errRestartInProgress.Error())
+            return
+        }
+        fmt.Fprint(responseWriter, "Server restart initiated.")
+        //The shutdown slot above is already claimed, so this handler's own connection does not have
+        //to stay open for the restart to complete; running it in a goroutine lets the handler return
+        //(and the connection go idle) instead of blocking on oldHttpServer.Shutdown, which cannot
+        //finish until this very connection does.
+        go func() {
+            if err := phs.restartAfterClaimingShutdown(); err != nil {
+                phs.logger.Printf("ERROR: Unable to restart server:\n %v\n", err)
+            }
+        }()
+    })
+}
+
+//Gracefully restarts the server in place: drains in-flight requests, shuts down the HTTP server, then
+//rebinds to the same address, preserving hashStats and threadInfo across the restart. The goroutine
+//already blocked in StartServer picks up the rebuilt httpServer and resumes serving.
+func (phs *PwdHashServer) Restart() error {
+    if !phs.beginShutdown() {
+        return errRestartInProgress
     }
+    return phs.restartAfterClaimingShutdown()
+}
+
+//Performs the restart itself, assuming the caller has already won the shutdown slot via beginShutdown.
+func (phs *PwdHashServer) restartAfterClaimingShutdown() error {
+    phs.restartRequested.Store(true)
+    phs.logger.Print("Restarting server...")
+    phs.waitForWorkingThreads()
+
+    //phs.httpServer is swapped in before the old server is shut down, not after, so that StartServer's
+    //loop - which re-reads phs.httpServer as soon as the old listener closes - picks up the new server
+    //immediately rather than busy-looping on the one that just got shut down. Shutdown closes its
+    //listener right away regardless of whether its context times out waiting for connections to drain,
+    //so the new server is already serving well before a timeout error below is returned.
+    phs.httpServerMutex.Lock()
+    oldHttpServer := phs.httpServer
+    phs.httpServer = phs.getHttpServer(oldHttpServer.Addr)
+    phs.httpServerMutex.Unlock()
+
+    shutdownCtx, cancel := context.WithTimeout(context.Background(), restartShutdownTimeout)
+    defer cancel()
+    if err := oldHttpServer.Shutdown(shutdownCtx); err != nil {
+        phs.restartRequested.Store(false)
+        phs.shutdownInProgress.Store(false)
+        phs.logger.Printf("ERROR: Unable to shut down server for restart:\n %v\n", err)
+        return err
+    }
+
+    phs.shutdownInProgress.Store(false)
+    phs.restartRequested.Store(false)
+    phs.logger.Print("Server restarted.")
+    return nil
 }
 
 //Builds and returns the statistics handler.
 func (phs *PwdHashServer) getStatsHandler() http.HandlerFunc {
     return http.HandlerFunc(func (responseWriter http.ResponseWriter, request *http.Request) {
-        if phs.shutdownInProgress {
+        if !phs.admitRequest() {
+            return
+        }
+        phs.metrics.Counter(statsHitsBucket, 1)
+        if phs.injectChaosFailure(responseWriter) {
+            phs.decrementWorkingThreads()
+            return
+        }
+        if phs.injectChaosDrop(responseWriter) {
+            phs.decrementWorkingThreads()
             return
         }
-        phs.incrementWorkingThreads()
         phs.hashStats.totalHashedMutex.Lock()
         phs.hashStats.totalHashingTimeMutex.Lock()
         jsonStats := phs.getJsonStats(phs.hashStats.totalHashed, getAverageHashingTimeInMillis(phs.hashStats.totalHashed, phs.hashStats.totalHashingTime))
@@ -181,8 +339,23 @@ func (phs *PwdHashServer) getJsonStats(totalHashed int64, averageHashingTime int
     return string(jsonStatsMap)
 }
 
-//Starts the server.
+//Starts the server, resuming automatically after an in-process restart until a true shutdown occurs.
 func (phs *PwdHashServer) StartServer() {
-    phs.logger.Print("Starting server...")
-    phs.logger.Fatal(phs.httpServer.ListenAndServe())
+    for {
+        phs.logger.Print("Starting server...")
+        phs.httpServerMutex.Lock()
+        httpServer := phs.httpServer
+        phs.httpServerMutex.Unlock()
+        if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+            phs.logger.Fatal(err)
+        }
+        //A restart swaps in a new httpServer before shutting down the old one, so a swapped server
+        //means a restart even if the restarter has already cleared restartRequested.
+        phs.httpServerMutex.Lock()
+        swapped := phs.httpServer != httpServer
+        phs.httpServerMutex.Unlock()
+        if !swapped && !phs.restartRequested.Load() {
+            return
+        }
+    }
 }
@@ -117,6 +117,7 @@ func TestTotalHashingTimeIncrease(t *testing.T) {
 //Tests pwdhashservice.decrementWorkingThreads()
 func TestDecrementWorkingThreads(t *testing.T) {
     phs := NewPasswordHashingServer(":8080")
+    phs.incrementWorkingThreads()
     prevNumWorkingThreads := phs.threadInfo.numWorkingThreads
     phs.decrementWorkingThreads()
     currNumWorkingThreads := phs.threadInfo.numWorkingThreads
@@ -180,14 +181,14 @@ func TestShutdownHandler(t *testing.T) {
     request, _ := http.NewRequest("GET", "/shutdown", nil)
     response := httptest.NewRecorder()
     t.Run("TestShutdownInProgress", func(t *testing.T) {
-        phs.shutdownInProgress = true
+        phs.shutdownInProgress.Store(true)
         shutdownHandler(response, request)
         responseBody, err := ioutil.ReadAll(response.Body)
         correctResponseBody := "A server shutdown is already in progress."
         failOnIncorrectResponse(t, response, err, string(responseBody), correctResponseBody)
     })
     t.Run("TestShutdown", func(t *testing.T) {
-        phs.shutdownInProgress = false
+        phs.shutdownInProgress.Store(false)
         shutdownHandler(response, request)
         responseBody, err := ioutil.ReadAll(response.Body)
         correctResponseBody := ""
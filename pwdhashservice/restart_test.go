@@ -0,0 +1,97 @@
+package pwdhashservice
+
+import (
+    "context"
+    "net/http"
+    "net/http/httptest"
+    "sync"
+    "testing"
+    "time"
+)
+
+//Tests pwdhashservice.PwdHashServer.Restart() error
+func TestRestart(t *testing.T) {
+    phs := NewPasswordHashingServer("127.0.0.1:18234")
+    go phs.StartServer()
+    time.Sleep(100 * time.Millisecond)
+
+    prevHttpServer := phs.httpServer
+    if err := phs.Restart(); err != nil {
+        t.Fatalf("Unable to restart server: %v", err)
+    }
+    if phs.httpServer == prevHttpServer {
+        t.Fail()
+    }
+    if phs.shutdownInProgress.Load() {
+        t.Fail()
+    }
+    time.Sleep(100 * time.Millisecond)
+
+    response, err := http.Get("http://127.0.0.1:18234/stats")
+    if err != nil {
+        t.Fatalf("Unable to reach restarted server: %v", err)
+    }
+    response.Body.Close()
+    if response.StatusCode != 200 {
+        t.Fail()
+    }
+
+    phs.httpServer.Shutdown(context.Background())
+}
+
+//Tests that pwdhashservice.PwdHashServer.Restart() error rejects a second restart while one is in progress.
+func TestRestartAlreadyInProgress(t *testing.T) {
+    phs := NewPasswordHashingServer("127.0.0.1:18235")
+    phs.shutdownInProgress.Store(true)
+    if err := phs.Restart(); err != errRestartInProgress {
+        t.Logf("Expected errRestartInProgress, got: %v", err)
+        t.Fail()
+    }
+}
+
+//Tests that pwdhashservice.PwdHashServer.admitRequest() bool cannot race with waitForWorkingThreads:
+//once beginShutdown succeeds, every concurrent admitRequest call must either have already been
+//accounted for in the WaitGroup or be rejected outright.
+func TestAdmitRequestRaceWithShutdown(t *testing.T) {
+    phs := NewPasswordHashingServer(":8080")
+    var wg sync.WaitGroup
+    for i := 0; i < 100; i++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            if phs.admitRequest() {
+                phs.decrementWorkingThreads()
+            }
+        }()
+    }
+    phs.beginShutdown()
+    phs.waitForWorkingThreads()
+    wg.Wait()
+
+    if phs.admitRequest() {
+        t.Fail()
+    }
+}
+
+//Tests handler returned by pwdhashservice.getRestartHandler() http.HandlerFunc
+func TestRestartHandler(t *testing.T) {
+    phs := NewPasswordHashingServer("127.0.0.1:18236")
+    go phs.StartServer()
+    time.Sleep(100 * time.Millisecond)
+
+    restartHandler := phs.getRestartHandler()
+    request, _ := http.NewRequest("GET", "/restart", nil)
+    response := httptest.NewRecorder()
+    restartHandler(response, request)
+    if response.Code != 200 {
+        t.Fail()
+    }
+
+    //The handler runs the restart asynchronously so it can respond to this request before its own
+    //connection is torn down; wait for the restart to finish before inspecting server state.
+    for i := 0; i < 50 && phs.shutdownInProgress.Load(); i++ {
+        time.Sleep(10 * time.Millisecond)
+    }
+
+    phs.httpServer.Shutdown(context.Background())
+}